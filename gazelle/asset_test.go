@@ -0,0 +1,40 @@
+package gazelle
+
+import "testing"
+
+func TestAssetImportSpec(t *testing.T) {
+	cfg := &Config{}
+
+	tests := []struct {
+		name    string
+		path    string
+		wantImp string
+		wantOk  bool
+	}{
+		{"plain css", "./styles.css", "./styles.css", true},
+		{"scss", "./styles.scss", "./styles.scss", true},
+		{"image", "./logo.svg", "./logo.svg", true},
+		{"query-url suffix stripped", "./logo.svg?url", "./logo.svg", true},
+		{"webpack loader prefix stripped", "style!./styles.css", "./styles.css", true},
+		{"loader and query suffix both stripped", "raw!./logo.svg?url", "./logo.svg", true},
+		{"non-asset extension", "./component.ts", "", false},
+		{"no extension", "./component", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			imp, ok := assetImportSpec(cfg, tt.path)
+			if ok != tt.wantOk {
+				t.Fatalf("assetImportSpec(%q) ok = %v, want %v", tt.path, ok, tt.wantOk)
+			}
+			if !ok {
+				return
+			}
+			if imp.Lang != assetLanguageName {
+				t.Errorf("assetImportSpec(%q) Lang = %q, want %q", tt.path, imp.Lang, assetLanguageName)
+			}
+			if imp.Imp != tt.wantImp {
+				t.Errorf("assetImportSpec(%q) Imp = %q, want %q", tt.path, imp.Imp, tt.wantImp)
+			}
+		})
+	}
+}
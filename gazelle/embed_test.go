@@ -0,0 +1,70 @@
+package gazelle
+
+import "testing"
+
+func TestDeclarationOnlySrcs(t *testing.T) {
+	tests := []struct {
+		srcs []string
+		want bool
+	}{
+		{nil, false},
+		{[]string{}, false},
+		{[]string{"foo.d.ts"}, true},
+		{[]string{"foo.d.ts", "bar.d.ts"}, true},
+		{[]string{"foo.d.ts", "bar.ts"}, false},
+		{[]string{"foo.ts"}, false},
+	}
+	for _, tt := range tests {
+		if got := declarationOnlySrcs(tt.srcs); got != tt.want {
+			t.Errorf("declarationOnlySrcs(%v) = %v, want %v", tt.srcs, got, tt.want)
+		}
+	}
+}
+
+func TestImplementationMatchesDeclaration(t *testing.T) {
+	tests := []struct {
+		name     string
+		implSrcs []string
+		declSrcs []string
+		want     bool
+	}{
+		{
+			name:     "ts counterpart",
+			implSrcs: []string{"foo.ts"},
+			declSrcs: []string{"foo.d.ts"},
+			want:     true,
+		},
+		{
+			name:     "tsx counterpart",
+			implSrcs: []string{"foo.tsx"},
+			declSrcs: []string{"foo.d.ts"},
+			want:     true,
+		},
+		{
+			name:     "one of several declarations matches",
+			implSrcs: []string{"bar.ts"},
+			declSrcs: []string{"foo.d.ts", "bar.d.ts"},
+			want:     true,
+		},
+		{
+			name:     "no matching basename",
+			implSrcs: []string{"bar.ts"},
+			declSrcs: []string{"foo.d.ts"},
+			want:     false,
+		},
+		{
+			name:     "no declarations",
+			implSrcs: []string{"foo.ts"},
+			declSrcs: nil,
+			want:     false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := implementationMatchesDeclaration(tt.implSrcs, tt.declSrcs); got != tt.want {
+				t.Errorf("implementationMatchesDeclaration(%v, %v) = %v, want %v",
+					tt.implSrcs, tt.declSrcs, got, tt.want)
+			}
+		})
+	}
+}
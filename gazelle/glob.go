@@ -0,0 +1,203 @@
+package gazelle
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/bazelbuild/buildtools/build"
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// globCacheKey identifies a resolved glob() call for caching purposes: the
+// package it was evaluated in, plus a hash of its include/exclude patterns
+// so two glob() calls in the same package (e.g. on different rules) that
+// happen to share patterns only walk the filesystem once.
+type globCacheKey struct {
+	pkg  string
+	hash string
+}
+
+var (
+	globCacheMu sync.Mutex
+	globCache   = make(map[globCacheKey][]string)
+)
+
+// globSrcs evaluates a `srcs = glob([...], exclude = [...])` expression
+// against the filesystem rooted at dir (the package directory), returning
+// the concrete, package-relative file list. ok is false if attr isn't a
+// glob() call, in which case the caller should fall back to AttrStrings.
+func globSrcs(dir, pkg string, attr build.Expr) (srcs []string, ok bool) {
+	call, isCall := attr.(*build.CallExpr)
+	if !isCall {
+		return nil, false
+	}
+	if ident, isIdent := call.X.(*build.Ident); !isIdent || ident.Name != "glob" {
+		return nil, false
+	}
+
+	var include, exclude []string
+	for i, arg := range call.List {
+		switch a := arg.(type) {
+		case *build.ListExpr:
+			// Positional: glob([...include...], [...exclude...]).
+			switch i {
+			case 0:
+				include = stringsFromList(a)
+			case 1:
+				exclude = stringsFromList(a)
+			}
+		case *build.AssignExpr:
+			list, isList := a.RHS.(*build.ListExpr)
+			if !isList {
+				continue
+			}
+			switch assignName(a) {
+			case "include":
+				include = stringsFromList(list)
+			case "exclude":
+				exclude = stringsFromList(list)
+			}
+		}
+	}
+	if len(include) == 0 {
+		return nil, true
+	}
+
+	key := globCacheKey{pkg: pkg, hash: hashPatterns(include, exclude)}
+
+	globCacheMu.Lock()
+	if cached, found := globCache[key]; found {
+		globCacheMu.Unlock()
+		return cached, true
+	}
+	globCacheMu.Unlock()
+
+	ignores, ignoreRoot := bazelIgnorePatterns(dir)
+
+	// .bazelignore patterns are relative to ignoreRoot (typically the
+	// workspace root), but doublestar.Glob below returns paths relative to
+	// dir (this package). Rebase dir's own matches onto ignoreRoot before
+	// comparing, or the prefix check in isBazelIgnored never lines up.
+	pkgRel := ""
+	if ignoreRoot != "" {
+		if rel, err := filepath.Rel(ignoreRoot, dir); err == nil && rel != "." {
+			pkgRel = filepath.ToSlash(rel)
+		}
+	}
+
+	matched := make(map[string]bool)
+	for _, pattern := range include {
+		files, err := doublestar.Glob(os.DirFS(dir), pattern)
+		if err != nil {
+			continue
+		}
+		for _, f := range files {
+			rootRelF := f
+			if pkgRel != "" {
+				rootRelF = path.Join(pkgRel, f)
+			}
+			if isExcluded(f, exclude) || isBazelIgnored(rootRelF, ignores) {
+				continue
+			}
+			matched[f] = true
+		}
+	}
+
+	srcs = make([]string, 0, len(matched))
+	for f := range matched {
+		srcs = append(srcs, f)
+	}
+
+	globCacheMu.Lock()
+	globCache[key] = srcs
+	globCacheMu.Unlock()
+
+	return srcs, true
+}
+
+func isExcluded(file string, exclude []string) bool {
+	for _, pattern := range exclude {
+		if ok, _ := doublestar.Match(pattern, file); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func isBazelIgnored(file string, ignores []string) bool {
+	for _, ignore := range ignores {
+		if file == ignore || strings.HasPrefix(file, ignore+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// bazelIgnorePatterns reads the workspace's .bazelignore (if any), walking
+// up from dir to find it, and returns the directory prefixes it names
+// (relative to the returned root - NOT to dir, since .bazelignore's own
+// patterns are always relative to wherever it lives, typically the
+// workspace root above dir). root is "" if no .bazelignore was found.
+func bazelIgnorePatterns(dir string) (patterns []string, root string) {
+	root = dir
+	for {
+		if _, err := os.Stat(filepath.Join(root, ".bazelignore")); err == nil {
+			break
+		}
+		parent := filepath.Dir(root)
+		if parent == root {
+			return nil, ""
+		}
+		root = parent
+	}
+
+	data, err := os.ReadFile(filepath.Join(root, ".bazelignore"))
+	if err != nil {
+		return nil, ""
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		patterns = append(patterns, line)
+	}
+	return patterns, root
+}
+
+func hashPatterns(include, exclude []string) string {
+	h := sha1.New()
+	for _, p := range include {
+		h.Write([]byte(p))
+		h.Write([]byte{0})
+	}
+	h.Write([]byte{0})
+	for _, p := range exclude {
+		h.Write([]byte(p))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func stringsFromList(list *build.ListExpr) []string {
+	out := make([]string, 0, len(list.List))
+	for _, e := range list.List {
+		if s, ok := e.(*build.StringExpr); ok {
+			out = append(out, s.Value)
+		}
+	}
+	return out
+}
+
+func assignName(a *build.AssignExpr) string {
+	if ident, ok := a.LHS.(*build.Ident); ok {
+		return ident.Name
+	}
+	return ""
+}
@@ -0,0 +1,111 @@
+package gazelle
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bazelbuild/buildtools/build"
+)
+
+func TestIsExcluded(t *testing.T) {
+	tests := []struct {
+		file    string
+		exclude []string
+		want    bool
+	}{
+		{"foo.ts", nil, false},
+		{"foo.test.ts", []string{"**/*.test.ts"}, true},
+		{"sub/foo.test.ts", []string{"**/*.test.ts"}, true},
+		{"foo.ts", []string{"**/*.test.ts"}, false},
+	}
+	for _, tt := range tests {
+		if got := isExcluded(tt.file, tt.exclude); got != tt.want {
+			t.Errorf("isExcluded(%q, %v) = %v, want %v", tt.file, tt.exclude, got, tt.want)
+		}
+	}
+}
+
+func TestIsBazelIgnored(t *testing.T) {
+	ignores := []string{"vendor", "dist/gen"}
+	tests := []struct {
+		file string
+		want bool
+	}{
+		{"vendor", true},
+		{"vendor/foo.ts", true},
+		{"dist/gen/foo.ts", true},
+		{"dist/generated/foo.ts", false},
+		{"src/foo.ts", false},
+	}
+	for _, tt := range tests {
+		if got := isBazelIgnored(tt.file, ignores); got != tt.want {
+			t.Errorf("isBazelIgnored(%q, %v) = %v, want %v", tt.file, ignores, got, tt.want)
+		}
+	}
+}
+
+func TestBazelIgnorePatternsRebasing(t *testing.T) {
+	root := t.TempDir()
+	pkgDir := filepath.Join(root, "some", "pkg")
+	if err := os.MkdirAll(pkgDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	ignoreContents := "some/pkg/vendor\n# a comment\n\nother/dir\n"
+	if err := os.WriteFile(filepath.Join(root, ".bazelignore"), []byte(ignoreContents), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	patterns, foundRoot := bazelIgnorePatterns(pkgDir)
+	if foundRoot != root {
+		t.Fatalf("bazelIgnorePatterns root = %q, want %q", foundRoot, root)
+	}
+	want := []string{"some/pkg/vendor", "other/dir"}
+	if len(patterns) != len(want) {
+		t.Fatalf("bazelIgnorePatterns patterns = %v, want %v", patterns, want)
+	}
+	for i := range want {
+		if patterns[i] != want[i] {
+			t.Fatalf("bazelIgnorePatterns patterns = %v, want %v", patterns, want)
+		}
+	}
+
+	// The patterns above are root-relative ("some/pkg/vendor"), so a file
+	// found by globbing pkgDir itself ("vendor/foo.ts", relative to pkgDir)
+	// must be rebased onto "some/pkg/vendor/foo.ts" before comparing - the
+	// bug this test guards against compared the two bases directly.
+	rel, err := filepath.Rel(foundRoot, pkgDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rootRelFile := filepath.ToSlash(filepath.Join(rel, "vendor/foo.ts"))
+	if !isBazelIgnored(rootRelFile, patterns) {
+		t.Errorf("isBazelIgnored(%q, %v) = false, want true", rootRelFile, patterns)
+	}
+}
+
+func TestGlobSrcsPositionalExclude(t *testing.T) {
+	dir := t.TempDir()
+	for _, f := range []string{"foo.ts", "foo.test.ts"} {
+		if err := os.WriteFile(filepath.Join(dir, f), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	f, err := build.ParseBuild("BUILD.bazel", []byte(`srcs = glob(["**/*.ts"], ["**/*.test.ts"])`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assign, ok := f.Stmt[0].(*build.AssignExpr)
+	if !ok {
+		t.Fatalf("expected an AssignExpr, got %T", f.Stmt[0])
+	}
+
+	srcs, ok := globSrcs(dir, "some/pkg", assign.RHS)
+	if !ok {
+		t.Fatal("globSrcs: ok = false, want true")
+	}
+	if len(srcs) != 1 || srcs[0] != "foo.ts" {
+		t.Errorf("globSrcs positional exclude = %v, want [foo.ts]", srcs)
+	}
+}
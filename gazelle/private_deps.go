@@ -0,0 +1,36 @@
+package gazelle
+
+import (
+	bzl "github.com/bazelbuild/buildtools/build"
+
+	"github.com/bazelbuild/bazel-gazelle/rule"
+)
+
+// resolvedDepsKey is a private attribute a GenerateRules-time macro, or a
+// user-authored fragment in an unmanaged subsection of the BUILD file, can
+// set on a rule to contribute deps that Resolve should union into the final
+// "deps" list without trying to re-derive them itself. Mirrors the Python
+// gazelle extension's equivalent mechanism.
+const resolvedDepsKey = "_gazelle_ts_resolved_deps"
+
+// keptDeps returns the elements of r's current "deps" attribute that carry a
+// "# keep" comment, as their original *bzl.StringExpr nodes so the comment
+// survives being written back out - rebuilding them as plain strings would
+// silently strip the very marker that's supposed to pin them permanently.
+func keptDeps(r *rule.Rule) []*bzl.StringExpr {
+	listExpr, ok := r.Attr("deps").(*bzl.ListExpr)
+	if !ok {
+		return nil
+	}
+
+	var kept []*bzl.StringExpr
+	for _, e := range listExpr.List {
+		if !rule.ShouldKeep(e) {
+			continue
+		}
+		if s, ok := e.(*bzl.StringExpr); ok {
+			kept = append(kept, s)
+		}
+	}
+	return kept
+}
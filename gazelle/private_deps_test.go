@@ -0,0 +1,46 @@
+package gazelle
+
+import (
+	"testing"
+
+	bzl "github.com/bazelbuild/buildtools/build"
+	"github.com/emirpasic/gods/sets/treeset"
+)
+
+func TestConvertDependencySetToExprDedupesKept(t *testing.T) {
+	set := treeset.NewWithStringComparator()
+	set.Add("//some/pkg:a", "//some/pkg:b")
+
+	kept := []*bzl.StringExpr{
+		{Value: "//some/pkg:a", Comments: bzl.Comments{Suffix: []bzl.Comment{{Token: "# keep"}}}},
+	}
+
+	expr := convertDependencySetToExpr(set, kept)
+	list, ok := expr.(*bzl.ListExpr)
+	if !ok {
+		t.Fatalf("convertDependencySetToExpr returned %T, want *bzl.ListExpr", expr)
+	}
+
+	var values []string
+	var aCount int
+	for _, e := range list.List {
+		s, ok := e.(*bzl.StringExpr)
+		if !ok {
+			t.Fatalf("list element %v is %T, want *bzl.StringExpr", e, e)
+		}
+		values = append(values, s.Value)
+		if s.Value == "//some/pkg:a" {
+			aCount++
+			if len(s.Comments.Suffix) == 0 {
+				t.Errorf("kept node for %q lost its comment", s.Value)
+			}
+		}
+	}
+
+	if aCount != 1 {
+		t.Errorf("//some/pkg:a appeared %d times, want exactly 1 (the kept node)", aCount)
+	}
+	if len(values) != 2 {
+		t.Errorf("convertDependencySetToExpr values = %v, want 2 entries", values)
+	}
+}
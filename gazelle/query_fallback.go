@@ -0,0 +1,189 @@
+package gazelle
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os/exec"
+	"path"
+	"strings"
+	"sync"
+)
+
+// TsQueryFallbackDirective enables an extra resolution stage, run after all
+// in-process resolvers have failed for every still-unresolved import in a
+// package and before ValidateImportStatements() would raise a fatal error,
+// that shells out to a single `bazel query` to find the rules producing
+// those imports' files. This gives a migration path for monorepos where not
+// every BUILD file is Gazelle-managed yet. Off by default: it's slow, and
+// requires a working bazel invocation during `gazelle update`.
+//
+//	# gazelle:ts_query_fallback true
+const TsQueryFallbackDirective = "ts_query_fallback"
+
+// queryFallbackExtensions are the extensions tried, in order, when turning
+// an unresolved import path into a candidate file to query for.
+var queryFallbackExtensions = []string{".ts", ".tsx", ".d.ts"}
+
+// queryManagedKinds are the rule kinds considered candidate owners of a file
+// discovered via the bazel query fallback; matches other than these are
+// ignored so e.g. a generated filegroup doesn't get picked as a dep.
+var queryManagedKinds = map[string]bool{
+	"ts_project":     true,
+	"js_library":     true,
+	"ts_declaration": true,
+}
+
+var (
+	queryCacheMu sync.Mutex
+	// queryCache is keyed by a candidate file's repo-relative path ->
+	// resolved label ("" = no match). The query result depends only on
+	// which rule owns that file, not on who's importing it, so this is
+	// shared workspace-wide rather than per importing package.
+	queryCache = make(map[string]string)
+)
+
+// queryFallbackResolveBatch resolves every import in mods that doesn't
+// already have a cached answer with a single `bazel query`, instead of one
+// (or up to len(queryFallbackExtensions)) subprocess invocations per import -
+// the point of running this once per package rather than once per import.
+// It returns a map from ImportStatement.Path to the resolved label, for
+// whichever of mods actually resolved.
+func queryFallbackResolveBatch(mods []ImportStatement) map[string]string {
+	resolved := make(map[string]string, len(mods))
+
+	// importPath -> its not-yet-cached candidate files, most-specific
+	// extension first.
+	pending := make(map[string][]string, len(mods))
+	var toQuery []string
+
+	for _, mod := range mods {
+		if _, done := resolved[mod.Path]; done {
+			continue
+		}
+		for _, ext := range queryFallbackExtensions {
+			file := mod.Path + ext
+
+			queryCacheMu.Lock()
+			label, cached := queryCache[file]
+			queryCacheMu.Unlock()
+
+			if cached {
+				if label != "" {
+					resolved[mod.Path] = label
+					break
+				}
+				continue
+			}
+
+			pending[mod.Path] = append(pending[mod.Path], file)
+			toQuery = append(toQuery, file)
+		}
+	}
+
+	if len(toQuery) == 0 {
+		return resolved
+	}
+
+	found := runBatchQuery(toQuery)
+
+	queryCacheMu.Lock()
+	for _, file := range toQuery {
+		queryCache[file] = found[file]
+	}
+	queryCacheMu.Unlock()
+
+	for importPath, files := range pending {
+		if _, done := resolved[importPath]; done {
+			continue
+		}
+		for _, file := range files {
+			if label := found[file]; label != "" {
+				resolved[importPath] = label
+				break
+			}
+		}
+	}
+
+	return resolved
+}
+
+// runBatchQuery issues one workspace-wide `bazel query` covering every file
+// in files (each a repo-relative path, e.g. "some/pkg/name.ts"), and returns
+// a map from file to the label of the managed rule that produces it ("" or
+// absent if none was found).
+//
+// same_pkg_direct_rdeps(set(...)) is used instead of len(files) separate
+// same_pkg_direct_rdeps(<file>) queries; the result for each file is
+// guaranteed to live in that file's own package, so matches are attributed
+// back to files by package rather than by file name - exactly as the
+// original, one-file-at-a-time version implicitly did per query, just now
+// computed once for the whole batch.
+func runBatchQuery(files []string) map[string]string {
+	labels := make([]string, len(files))
+	for i, f := range files {
+		labels[i] = fileLabel(f)
+	}
+
+	query := fmt.Sprintf("same_pkg_direct_rdeps(set(%s))", strings.Join(labels, " "))
+	cmd := exec.Command("bazel", "query", query, "--output=label_kind")
+	out, err := cmd.Output()
+	if err != nil {
+		log.Printf("WARN: ts_query_fallback: `bazel query %s` failed: %v", query, err)
+		return nil
+	}
+
+	labelByPkg := make(map[string]string)
+	scanner := bufio.NewScanner(strings.NewReader(string(out)))
+	for scanner.Scan() {
+		// label_kind output lines look like "ts_project rule //some/pkg:name".
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 3 {
+			continue
+		}
+		kind, target := fields[0], fields[2]
+		if !queryManagedKinds[kind] {
+			continue
+		}
+		if _, found := labelByPkg[labelPkg(target)]; !found {
+			labelByPkg[labelPkg(target)] = target
+		}
+	}
+
+	result := make(map[string]string, len(files))
+	for _, f := range files {
+		result[f] = labelByPkg[filePkg(f)]
+	}
+	return result
+}
+
+// fileLabel turns a repo-relative file path into the label of its implicit
+// source-file target, special-casing the workspace root package: path.Dir
+// of a root-level file is ".", which would otherwise produce the invalid
+// label "//.:foo.ts" instead of "//:foo.ts".
+func fileLabel(file string) string {
+	dir := filePkg(file)
+	if dir == "" {
+		return "//:" + path.Base(file)
+	}
+	return fmt.Sprintf("//%s:%s", dir, path.Base(file))
+}
+
+// filePkg returns file's package, the empty string for the workspace root
+// package (matching labelPkg's convention for "//:target").
+func filePkg(file string) string {
+	dir := path.Dir(file)
+	if dir == "." {
+		return ""
+	}
+	return dir
+}
+
+// labelPkg extracts the package portion of a "//pkg:name" label.
+func labelPkg(target string) string {
+	pkg := strings.TrimPrefix(target, "//")
+	if idx := strings.Index(pkg, ":"); idx >= 0 {
+		pkg = pkg[:idx]
+	}
+	return pkg
+}
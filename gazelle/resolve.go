@@ -18,6 +18,69 @@ import (
 
 var EXPLAIN_DEPENDENCY = os.Getenv("EXPLAIN_DEPENDENCY")
 
+// assetLanguageName is the resolve.ImportSpec.Lang used for non-TypeScript
+// assets (CSS/Sass/images/etc) so they're indexed and resolved separately
+// from ".ts"/".tsx" imports, without risking collisions between e.g. a
+// "./foo" TS import and a "./foo.css" asset import that happen to share a
+// stripped path.
+const assetLanguageName = languageName + "_asset"
+
+// defaultAssetExtensions are the non-TypeScript file extensions that are
+// indexed and resolved as assets out of the box. Extend with the
+// "ts_asset_extensions" directive.
+var defaultAssetExtensions = []string{
+	".css", ".scss", ".sass",
+	".svg", ".png", ".jpg", ".jpeg", ".gif", ".webp",
+}
+
+// assetSourceKinds are the rule kinds, besides ts_project itself, whose
+// srcs/data are indexed as importable assets - e.g. a css_library or
+// filegroup sitting next to a ts_project that imports from it.
+var assetSourceKinds = map[string]bool{
+	"filegroup":    true,
+	"css_library":  true,
+	"sass_library": true,
+	"assets":       true,
+}
+
+// TsAssetExtensionsDirective lets a package extend the set of non-TS file
+// extensions indexed and resolved as assets, beyond defaultAssetExtensions.
+//
+//	# gazelle:ts_asset_extensions .graphql,.mdx
+const TsAssetExtensionsDirective = "ts_asset_extensions"
+
+// assetImportSpec builds the ImportSpec used to index or resolve a
+// "./styles.scss"-style asset import, or (false) if path doesn't end in a
+// recognized asset extension for this package.
+func assetImportSpec(cfg *Config, path string) (resolve.ImportSpec, bool) {
+	// Webpack-style loader/query suffixes ("style!./foo.css", "./foo.svg?url")
+	// carry no information about the underlying file's own import path.
+	path = strings.TrimSuffix(path, "?url")
+	if idx := strings.LastIndex(path, "!"); idx >= 0 {
+		path = path[idx+1:]
+	}
+
+	ext := filepath.Ext(path)
+	for _, assetExt := range defaultAssetExtensions {
+		if ext == assetExt {
+			return resolve.ImportSpec{Lang: assetLanguageName, Imp: path}, true
+		}
+	}
+	for _, assetExt := range cfg.GetTsAssetExtensions() {
+		if ext == assetExt {
+			return resolve.ImportSpec{Lang: assetLanguageName, Imp: path}, true
+		}
+	}
+	return resolve.ImportSpec{}, false
+}
+
+// TsEmbedDirective lets a BUILD file explicitly declare that a ts_project
+// embeds a sibling declaration-only target, e.g. when the two targets don't
+// share a common source basename and can't be matched automatically.
+//
+//	# gazelle:ts_embed //some/pkg:types
+const TsEmbedDirective = "ts_embed"
+
 // Name returns the name of the language. This is the prefix of the kinds of
 // rules generated. E.g. ts_project
 func (*TypeScript) Name() string { return languageName }
@@ -25,12 +88,25 @@ func (*TypeScript) Name() string { return languageName }
 // Determine what rule (r) outputs which can be imported.
 // For TypeScript this is all the import-paths pointing to files within the rule.
 func (ts *TypeScript) Imports(c *config.Config, r *rule.Rule, f *rule.File) []resolve.ImportSpec {
-	srcs := r.AttrStrings("srcs")
-	provides := make([]resolve.ImportSpec, 0, len(srcs)+1)
-
 	cfgs := c.Exts[languageName].(Configs)
 	cfg := cfgs[f.Pkg]
 
+	// Rules like filegroup/css_library/sass_library that merely carry
+	// assets (not TypeScript sources) are indexed under assetLanguageName so
+	// a ".tsx" file's "./styles.scss" import resolves to them.
+	if assetSourceKinds[r.Kind()] {
+		return ts.assetImports(cfg, r, f)
+	}
+
+	ts.resolveTsEmbed(cfg, r, f)
+
+	srcs, ok := globSrcs(filepath.Dir(f.Path), f.Pkg, r.Attr("srcs"))
+	if !ok {
+		// srcs isn't a glob() call; fall back to the plain string list.
+		srcs = r.AttrStrings("srcs")
+	}
+	provides := make([]resolve.ImportSpec, 0, len(srcs)+1)
+
 	baseDir := cfg.GetTsCompilerOptions().BaseDir
 
 	for _, src := range srcs {
@@ -74,13 +150,120 @@ func (ts *TypeScript) Imports(c *config.Config, r *rule.Rule, f *rule.File) []re
 	return provides
 }
 
+// assetImports indexes the srcs and data of an asset-only rule (filegroup,
+// css_library, sass_library, assets) under assetLanguageName, so a sibling
+// ts_project importing e.g. "./styles.scss" resolves to it.
+func (ts *TypeScript) assetImports(cfg *Config, r *rule.Rule, f *rule.File) []resolve.ImportSpec {
+	var provides []resolve.ImportSpec
+
+	for _, attr := range []string{"srcs", "data"} {
+		for _, src := range r.AttrStrings(attr) {
+			src = filepath.Clean(filepath.Join(f.Pkg, src))
+			if spec, ok := assetImportSpec(cfg, src); ok {
+				provides = append(provides, spec)
+			}
+		}
+	}
+
+	DEBUG("PROVIDES(%q): %v", r.Name(), provides)
+
+	return provides
+}
+
 // Embeds returns a list of labels of rules that the given rule embeds. If
 // a rule is embedded by another importable rule of the same language, only
 // the embedding rule will be indexed. The embedding rule will inherit
 // the imports of the embedded rule.
+//
+// resolveTsEmbed is what actually populates the "embed" attribute this
+// reads; Embeds itself just has to parse it back into labels, same as
+// go_test embedding go_library.
 func (ts *TypeScript) Embeds(r *rule.Rule, from label.Label) []label.Label {
-	// TODO(jbedard): implement.
-	return make([]label.Label, 0)
+	embedStrs := r.AttrStrings("embed")
+	embeds := make([]label.Label, 0, len(embedStrs))
+	for _, s := range embedStrs {
+		l, err := label.Parse(s)
+		if err != nil {
+			log.Printf("ERROR: %q has invalid embed label %q: %v", from.String(), s, err)
+			continue
+		}
+		embeds = append(embeds, l.Abs(from.Repo, from.Pkg))
+	}
+	return embeds
+}
+
+// tsProjectKind is the rule kind produced for TypeScript sources; declared
+// here (rather than inlined) since resolveTsEmbed needs to recognize a
+// rule's own siblings by kind, the same way assetSourceKinds recognizes
+// asset rules.
+const tsProjectKind = "ts_project"
+
+// resolveTsEmbed sets r's "embed" attribute, if not already present, to the
+// sibling ts_project in the same package (visible via f.Rules, since Embeds
+// itself is only ever handed one rule at a time and can't discover this on
+// its own) that r embeds: the target named by an explicit "ts_embed"
+// directive if one is configured for r, or failing that the declaration-only
+// (".d.ts"-only srcs) ts_project whose files r's own srcs implement. This
+// mirrors how go_test/go_library embedding is resolved during generation,
+// except here it runs the first time Imports() sees the implementation rule,
+// since that's the earliest point with access to the rest of the package.
+func (ts *TypeScript) resolveTsEmbed(cfg *Config, r *rule.Rule, f *rule.File) {
+	if r.Kind() != tsProjectKind || len(r.AttrStrings("embed")) > 0 {
+		return
+	}
+
+	if explicit := cfg.GetTsEmbed(r.Name()); explicit != "" {
+		r.SetAttr("embed", []string{explicit})
+		return
+	}
+
+	implSrcs := r.AttrStrings("srcs")
+	if declarationOnlySrcs(implSrcs) {
+		// r is itself the declaration-only half of the pair; it has
+		// nothing to embed.
+		return
+	}
+
+	for _, sibling := range f.Rules {
+		if sibling == r || sibling.Kind() != tsProjectKind {
+			continue
+		}
+		declSrcs := sibling.AttrStrings("srcs")
+		if declarationOnlySrcs(declSrcs) && implementationMatchesDeclaration(implSrcs, declSrcs) {
+			r.SetAttr("embed", []string{":" + sibling.Name()})
+			return
+		}
+	}
+}
+
+// declarationOnlySrcs reports whether every entry in srcs is a ".d.ts" file,
+// i.e. the rule has nothing to offer except type information and is a
+// candidate to be embedded into the sibling rule that implements it.
+func declarationOnlySrcs(srcs []string) bool {
+	if len(srcs) == 0 {
+		return false
+	}
+	for _, src := range srcs {
+		if !strings.HasSuffix(src, ".d.ts") {
+			return false
+		}
+	}
+	return true
+}
+
+// implementationMatchesDeclaration reports whether implSrcs contains the
+// ".ts"/".tsx" counterpart of at least one ".d.ts" file in declSrcs, i.e.
+// "foo.ts" or "foo.tsx" for "foo.d.ts".
+func implementationMatchesDeclaration(implSrcs, declSrcs []string) bool {
+	for _, decl := range declSrcs {
+		base := strings.TrimSuffix(decl, ".d.ts")
+		for _, impl := range implSrcs {
+			if impl == base+".ts" || impl == base+".tsx" {
+				return true
+			}
+		}
+	}
+	return false
 }
 
 // Resolve translates imported libraries for a given rule into Bazel
@@ -102,10 +285,24 @@ func (ts *TypeScript) Resolve(
 
 	deps := ts.ResolveModuleDeps(c, ix, modulesRaw.(*treeset.Set), from)
 
+	// Deps attached to the rule out-of-band under resolvedDepsKey are
+	// unioned in rather than derived - Gazelle shouldn't try to re-derive
+	// something a macro or unmanaged BUILD fragment already decided.
+	if preResolved, ok := r.PrivateAttr(resolvedDepsKey).([]string); ok {
+		for _, dep := range preResolved {
+			deps.Add(dep)
+		}
+		r.SetPrivateAttr(resolvedDepsKey, nil)
+	}
+
 	DEBUG("RESOLVED(%s): %s => %s", from.Name, modulesRaw.(*treeset.Set).Values(), deps.Values())
 
-	if !deps.Empty() {
-		r.SetAttr("deps", convertDependencySetToExpr(deps))
+	// Deps a user has pinned with a "# keep" comment are spliced back in as
+	// their original nodes - not via deps, which only holds plain strings
+	// and would silently drop the comment that is the whole point of "# keep".
+	kept := keptDeps(r)
+	if !deps.Empty() || len(kept) > 0 {
+		r.SetAttr("deps", convertDependencySetToExpr(deps, kept))
 	}
 
 	tsconfig := cfg.GetTsConfigRule()
@@ -114,6 +311,60 @@ func (ts *TypeScript) Resolve(
 	}
 }
 
+// trimImportSegments returns imp's path progressively trimmed one segment at
+// a time, most-specific first, stopping at the package root: "lodash" for
+// "lodash/fp/get", or "@scope/pkg" (never split apart) for
+// "@scope/pkg/dist/sub". The untrimmed imp itself is not included.
+//
+// Only bare specifiers (not starting with "." or "/") are trimmed at all:
+// relative and absolute imports name a specific first-party file, and
+// walking up their path segments has no npm-style "package root" to stop
+// at, so it would just as happily wander into an unrelated sibling
+// directory as find the right one.
+func trimImportSegments(imp string) []string {
+	if strings.HasPrefix(imp, ".") || strings.HasPrefix(imp, "/") {
+		return nil
+	}
+
+	root := 1
+	if strings.HasPrefix(imp, "@") {
+		root = 2
+	}
+
+	var out []string
+	segments := strings.Split(imp, "/")
+	for len(segments) > root {
+		segments = segments[:len(segments)-1]
+		out = append(out, strings.Join(segments, "/"))
+	}
+	return out
+}
+
+// deepImportCandidates returns, most-specific first, the sequence of
+// trimmed-path fallbacks to try for a subpath/deep import such as
+// "lodash/fp/get" or "@scope/pkg/dist/sub" once the untrimmed imp itself has
+// already failed to resolve. A tsconfig "paths" alias for imp, if any, is
+// tried first since it's the most explicit signal available.
+//
+// This is deliberately NOT used for the first-party index lookup
+// (ix.FindRulesByImportWithConfig): trimming a path that genuinely doesn't
+// resolve (e.g. a typo'd first-party import) risks silently latching onto
+// an unrelated ancestor package's index-file target instead of surfacing
+// the "unknown dependency" error. It's only applied to the "@types/" npm
+// package lookup in ResolveModuleDeps, where "@types/lodash/fp/get" naturally
+// walking up to "@types/lodash" is exactly the desired behavior.
+func deepImportCandidates(cfg *Config, imp string) []string {
+	candidates := make([]string, 0, 4)
+
+	if alias, ok := cfg.GetTsConfigPath(imp); ok {
+		candidates = append(candidates, alias)
+	}
+
+	candidates = append(candidates, trimImportSegments(imp)...)
+
+	return candidates
+}
+
 func (ts *TypeScript) ResolveModuleDeps(
 	c *config.Config,
 	ix *resolve.RuleIndex,
@@ -126,16 +377,189 @@ func (ts *TypeScript) ResolveModuleDeps(
 	cfg := cfgs[from.Pkg]
 	hasFatalError := false
 
+	// Imports that reach the end of the per-import resolvers below without
+	// matching anything are collected here instead of immediately falling
+	// through to the bazel-query fallback, so that fallback can issue one
+	// query for the whole package below instead of one (or several, per
+	// extension) `bazel query` subprocesses per import.
+	var pending []ImportStatement
+
 	it := modules.Iterator()
+modLoop:
 	for it.Next() {
 		mod := it.Value().(ImportStatement)
-		imp := resolve.ImportSpec{
-			Lang: languageName,
-			Imp:  mod.Path,
+
+		// The untrimmed import (or its tsconfig "paths" alias) is tried
+		// first, with the first-party index lookup enabled - exactly as
+		// before progressive trimming was introduced.
+		primary := mod.Path
+		if alias, ok := cfg.GetTsConfigPath(mod.Path); ok {
+			primary = alias
+		}
+		resolved, fatal := ts.resolveImportCandidate(c, ix, cfg, from, mod, primary, true, deps)
+		if fatal {
+			hasFatalError = true
+		}
+		if resolved {
+			continue modLoop
+		}
+
+		// Only once that's failed do we progressively trim path segments
+		// and retry - but without the first-party index lookup, so this
+		// only ever lands on npm/named-package resolvers (see
+		// deepImportCandidates).
+		for _, candidate := range trimImportSegments(mod.Path) {
+			resolved, fatal := ts.resolveImportCandidate(c, ix, cfg, from, mod, candidate, false, deps)
+			if fatal {
+				hasFatalError = true
+			}
+			if resolved {
+				continue modLoop
+			}
+		}
+
+		// None of the progressively-trimmed candidates resolved; fall back
+		// to the @types/ pairing (tried with the same progressive search)
+		// before giving up on this import for now.
+		typesResolved := false
+		typesCandidates := append([]string{"@types/" + mod.Path}, deepImportCandidates(cfg, "@types/"+mod.Path)...)
+		for _, candidate := range typesCandidates {
+			if typePkg, typeFound := cfg.GetNpmPackage(candidate); typeFound {
+				deps.Add(typePkg)
+				if EXPLAIN_DEPENDENCY == typePkg {
+					log.Printf("Explaining dependency (%s): "+
+						"in the target %q, the file %q imports %q, "+
+						"which resolves from the third-party @types package %q.\n",
+						EXPLAIN_DEPENDENCY, from.String(), mod.SourcePath, mod.Path, typePkg)
+				}
+				typesResolved = true
+				break
+			}
+		}
+		if typesResolved {
+			continue
+		}
+
+		pending = append(pending, mod)
+	}
+
+	// Batch every import still unresolved in this package into a single
+	// `bazel query`, rather than shelling out once (or up to
+	// len(queryFallbackExtensions) times) per import.
+	queryResolved := map[string]string{}
+	if cfg.GetTsQueryFallback() && len(pending) > 0 {
+		queryResolved = queryFallbackResolveBatch(pending)
+	}
+
+	for _, mod := range pending {
+		if dep, found := queryResolved[mod.Path]; found {
+			deps.Add(dep)
+			if EXPLAIN_DEPENDENCY == dep {
+				log.Printf("Explaining dependency (%s): "+
+					"in the target %q, the file %q imports %q, "+
+					"which resolves via the \"ts_query_fallback\" bazel query.\n",
+					EXPLAIN_DEPENDENCY, from.String(), mod.SourcePath, mod.Path)
+			}
+			continue
+		}
+
+		if cfg.EnvironmentType() == EnvironmentNode && isNodeImport(mod.Path) {
+			// If this is a ts file importing a native node library include @types/node if it is available
+			if typePkg, typeFound := cfg.GetNpmPackage("@types/node"); typeFound {
+				deps.Add(typePkg)
+			}
+		} else if cfg.ValidateImportStatements() {
+			err := fmt.Errorf(
+				"Import %[1]q from %[2]q is an unknown dependency. Possible solutions:\n"+
+					"\t1. Instruct Gazelle to resolve to a known dependency using the gazelle:resolve directive.\n"+
+					"\t2. Ignore the dependency with a comment '# gazelle:%[3]s %[1]s' in the BUILD file",
+				mod.Path, mod.SourcePath, IgnoreImportsDirective,
+			)
+			log.Printf("ERROR: Failed to validate dependencies for target %q. %v", from.String(), err)
+			hasFatalError = true
+		}
+	}
+
+	if hasFatalError {
+		os.Exit(1)
+	}
+
+	return deps
+}
+
+// resolveImportCandidate tries a single (possibly trimmed) import path
+// against the same resolvers ResolveModuleDeps has always used, in the same
+// priority order, adding the winning dependency to deps. It reports whether
+// a dependency was resolved (or validly skipped, e.g. a self-import), so the
+// caller can stop walking the candidate list - the most specific candidate
+// to produce a match wins.
+//
+// allowIndexMatch gates the first-party resolvers (the "gazelle:resolve"
+// override, the asset-language index lookup, and the main import index
+// lookup): the caller only passes true for the untrimmed candidate, since a
+// trimmed candidate matching one of these would mean a first-party import
+// that genuinely failed to resolve silently latches onto some unrelated
+// ancestor package's target instead. Trimmed candidates still fall through
+// to the npm/named-package resolvers below, which is the actual point of
+// the progressive trimming.
+func (ts *TypeScript) resolveImportCandidate(
+	c *config.Config,
+	ix *resolve.RuleIndex,
+	cfg *Config,
+	from label.Label,
+	mod ImportStatement,
+	candidate string,
+	allowIndexMatch bool,
+	deps *treeset.Set,
+) (resolved bool, fatal bool) {
+	// CSS/Sass/image imports are indexed under a distinct language name, so
+	// look them up there instead of falling through the JS/TS-specific
+	// resolvers below (GetNamedPackage, npm packages, node builtins, etc.)
+	// which don't apply to them.
+	if assetImp, ok := assetImportSpec(cfg, candidate); ok {
+		if !allowIndexMatch {
+			return false, false
+		}
+		if matches := ix.FindRulesByImportWithConfig(c, assetImp, assetLanguageName); len(matches) > 0 {
+			filteredMatches := make([]resolve.FindResult, 0, len(matches))
+			for _, match := range matches {
+				if !match.IsSelfImport(from) {
+					filteredMatches = append(filteredMatches, match)
+				}
+			}
+			if len(filteredMatches) == 1 {
+				matchLabel := filteredMatches[0].Label.Rel(from.Repo, from.Pkg)
+				dep := matchLabel.String()
+				deps.Add(dep)
+				if EXPLAIN_DEPENDENCY == dep {
+					log.Printf("Explaining dependency (%s): "+
+						"in the target %q, the file %q imports %q, "+
+						"which resolves from the first-party indexed asset labels (via candidate %q).\n",
+						EXPLAIN_DEPENDENCY, from.String(), mod.SourcePath, mod.Path, candidate)
+				}
+				return true, false
+			} else if len(filteredMatches) > 1 {
+				err := fmt.Errorf(
+					"multiple targets (%s) may be imported with %q in %q "+
+						"- this must be fixed using the \"gazelle:resolve\" directive",
+					targetListFromResults(filteredMatches), candidate, mod.SourcePath)
+				log.Println("ERROR: ", err)
+				return true, true
+			}
+			// Every match was a self-import.
+			return true, false
 		}
+		return false, false
+	}
+
+	imp := resolve.ImportSpec{
+		Lang: languageName,
+		Imp:  candidate,
+	}
 
-		DEBUG("RESOLVE: %q from %q", imp.Imp, from.Name)
+	DEBUG("RESOLVE: %q (from import %q) from %q", imp.Imp, mod.Path, from.Name)
 
+	if allowIndexMatch {
 		if override, ok := resolve.FindRuleWithOverride(c, imp, languageName); ok {
 			if override.Repo == "" {
 				override.Repo = from.Repo
@@ -149,10 +573,11 @@ func (ts *TypeScript) ResolveModuleDeps(
 				if EXPLAIN_DEPENDENCY == dep {
 					log.Printf("Explaining dependency (%s): "+
 						"in the target %q, the file %q imports %q, "+
-						"which resolves using the \"gazelle:resolve\" directive.\n",
-						EXPLAIN_DEPENDENCY, from.String(), mod.SourcePath, mod.Path)
+						"which resolves using the \"gazelle:resolve\" directive (via candidate %q).\n",
+						EXPLAIN_DEPENDENCY, from.String(), mod.SourcePath, mod.Path, candidate)
 				}
 			}
+			return true, false
 		} else if matches := ix.FindRulesByImportWithConfig(c, imp, languageName); len(matches) > 0 {
 			filteredMatches := make([]resolve.FindResult, 0, len(matches))
 			for _, match := range matches {
@@ -171,76 +596,62 @@ func (ts *TypeScript) ResolveModuleDeps(
 				if EXPLAIN_DEPENDENCY == dep {
 					log.Printf("Explaining dependency (%s): "+
 						"in the target %q, the file %q imports %q, "+
-						"which resolves from the first-party indexed labels.\n",
-						EXPLAIN_DEPENDENCY, from.String(), mod.SourcePath, mod.Path)
+						"which resolves from the first-party indexed labels (via candidate %q).\n",
+						EXPLAIN_DEPENDENCY, from.String(), mod.SourcePath, mod.Path, candidate)
 				}
+				return true, false
 			} else if len(filteredMatches) > 1 {
 				err := fmt.Errorf(
 					"multiple targets (%s) may be imported with %q in %q "+
 						"- this must be fixed using the \"gazelle:resolve\" directive",
-					targetListFromResults(filteredMatches), mod.Path, mod.SourcePath)
+					targetListFromResults(filteredMatches), candidate, mod.SourcePath)
 				log.Println("ERROR: ", err)
-				hasFatalError = true
-			}
-		} else if pkg, found := ts.GetNamedPackage(mod.Path); found {
-			deps.Add(pkg)
-			if EXPLAIN_DEPENDENCY == pkg {
-				log.Printf("Explaining dependency (%s): "+
-					"in the target %q, the file %q imports %q, "+
-					"which resolves from the package declared at %q.\n",
-					EXPLAIN_DEPENDENCY, from.String(), mod.SourcePath, mod.Path, pkg)
-			}
-		} else if pkg, found := ts.GetImportableFile(mod.Path); found {
-			deps.Add(pkg)
-			if EXPLAIN_DEPENDENCY == pkg {
-				log.Printf("Explaining dependency (%s): "+
-					"in the target %q, the file %q imports %q, "+
-					"which resolves from the package declared at %q.\n",
-					EXPLAIN_DEPENDENCY, from.String(), mod.SourcePath, mod.Path, pkg)
-			}
-		} else if pkg, found := cfg.GetNpmPackage(mod.Path); found {
-			deps.Add(pkg)
-			if EXPLAIN_DEPENDENCY == pkg {
-				log.Printf("Explaining dependency (%s): "+
-					"in the target %q, the file %q imports %q, "+
-					"which resolves from the third-party package %q.\n",
-					EXPLAIN_DEPENDENCY, from.String(), mod.SourcePath, mod.Path, pkg)
-			}
-
-			// A package might also have a @types package, include it if the source file is type-checked.
-			if typePkg, typeFound := cfg.GetNpmPackage("@types/" + mod.Path); typeFound {
-				deps.Add(typePkg)
-			}
-		} else if typePkg, typeFound := cfg.GetNpmPackage("@types/" + mod.Path); typeFound {
-			deps.Add(typePkg)
-			if EXPLAIN_DEPENDENCY == typePkg {
-				log.Printf("Explaining dependency (%s): "+
-					"in the target %q, the file %q imports %q, "+
-					"which resolves from the third-party @types package %q.\n",
-					EXPLAIN_DEPENDENCY, from.String(), mod.SourcePath, mod.Path, typePkg)
-			}
-		} else if cfg.EnvironmentType() == EnvironmentNode && isNodeImport(mod.Path) {
-			// If this is a ts file importing a native node library include @types/node if it is available
-			if typePkg, typeFound := cfg.GetNpmPackage("@types/node"); typeFound {
-				deps.Add(typePkg)
+				return true, true
 			}
-		} else if cfg.ValidateImportStatements() {
-			err := fmt.Errorf(
-				"Import %[1]q from %[2]q is an unknown dependency. Possible solutions:\n"+
-					"\t1. Instruct Gazelle to resolve to a known dependency using the gazelle:resolve directive.\n"+
-					"\t2. Ignore the dependency with a comment '# gazelle:%[3]s %[1]s' in the BUILD file",
-				mod.Path, mod.SourcePath, IgnoreImportsDirective,
-			)
-			log.Printf("ERROR: Failed to validate dependencies for target %q. %v", from.String(), err)
-			hasFatalError = true
+			// Every match was a self-import.
+			return true, false
 		}
 	}
 
-	if hasFatalError {
-		os.Exit(1)
+	// The npm/named-package resolvers below apply regardless of
+	// allowIndexMatch: they can't accidentally latch onto an unrelated
+	// first-party target the way the index lookups above can, since they
+	// only match candidates against the declared npm/package surface.
+	if pkg, found := ts.GetNamedPackage(candidate); found {
+		deps.Add(pkg)
+		if EXPLAIN_DEPENDENCY == pkg {
+			log.Printf("Explaining dependency (%s): "+
+				"in the target %q, the file %q imports %q, "+
+				"which resolves from the package declared at %q (via candidate %q).\n",
+				EXPLAIN_DEPENDENCY, from.String(), mod.SourcePath, mod.Path, pkg, candidate)
+		}
+		return true, false
+	} else if pkg, found := ts.GetImportableFile(candidate); found {
+		deps.Add(pkg)
+		if EXPLAIN_DEPENDENCY == pkg {
+			log.Printf("Explaining dependency (%s): "+
+				"in the target %q, the file %q imports %q, "+
+				"which resolves from the package declared at %q (via candidate %q).\n",
+				EXPLAIN_DEPENDENCY, from.String(), mod.SourcePath, mod.Path, pkg, candidate)
+		}
+		return true, false
+	} else if pkg, found := cfg.GetNpmPackage(candidate); found {
+		deps.Add(pkg)
+		if EXPLAIN_DEPENDENCY == pkg {
+			log.Printf("Explaining dependency (%s): "+
+				"in the target %q, the file %q imports %q, "+
+				"which resolves from the third-party package %q (via candidate %q).\n",
+				EXPLAIN_DEPENDENCY, from.String(), mod.SourcePath, mod.Path, pkg, candidate)
+		}
+
+		// A package might also have a @types package, include it if the source file is type-checked.
+		if typePkg, typeFound := cfg.GetNpmPackage("@types/" + candidate); typeFound {
+			deps.Add(typePkg)
+		}
+		return true, false
 	}
 
-	return deps
+	return false, false
 }
 
 // targetListFromResults returns a string with the human-readable list of
@@ -253,14 +664,26 @@ func targetListFromResults(results []resolve.FindResult) string {
 	return strings.Join(list, ", ")
 }
 
-// convertDependencySetToExpr converts the given set of dependencies to an
-// expression to be used in the deps attribute.
-func convertDependencySetToExpr(set *treeset.Set) bzl.Expr {
-	deps := make([]bzl.Expr, set.Size())
+// convertDependencySetToExpr converts the given set of resolved dependencies,
+// plus any "# keep"-pinned nodes (spliced back in verbatim so their comment
+// survives), to an expression to be used in the deps attribute. A pinned dep
+// that's also in set is only emitted once, as the pinned node.
+func convertDependencySetToExpr(set *treeset.Set, kept []*bzl.StringExpr) bzl.Expr {
+	deps := make([]bzl.Expr, 0, set.Size()+len(kept))
+
+	keptValues := make(map[string]bool, len(kept))
+	for _, k := range kept {
+		deps = append(deps, k)
+		keptValues[k.Value] = true
+	}
+
 	it := set.Iterator()
 	for it.Next() {
 		dep := it.Value().(string)
-		deps[it.Index()] = &bzl.StringExpr{Value: dep}
+		if keptValues[dep] {
+			continue
+		}
+		deps = append(deps, &bzl.StringExpr{Value: dep})
 	}
 	return &bzl.ListExpr{List: deps}
 }
@@ -0,0 +1,26 @@
+package gazelle
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTrimImportSegments(t *testing.T) {
+	tests := []struct {
+		imp  string
+		want []string
+	}{
+		{"lodash/fp/get", []string{"lodash/fp", "lodash"}},
+		{"lodash", nil},
+		{"@scope/pkg/dist/sub", []string{"@scope/pkg/dist", "@scope/pkg"}},
+		{"@scope/pkg", nil},
+		{"./components/button", nil},
+		{"/abs/path/to/file", nil},
+	}
+	for _, tt := range tests {
+		got := trimImportSegments(tt.imp)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("trimImportSegments(%q) = %v, want %v", tt.imp, got, tt.want)
+		}
+	}
+}